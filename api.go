@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// asnPattern matches the ASN path param in both its bare ("15169") and
+// "AS"-prefixed ("AS15169") forms.
+var asnPattern = regexp.MustCompile(`^(AS)?[0-9]+$`)
+
+// defaultCacheTTL controls how long a lookupASN result is reused before
+// the API re-scans RIPE and re-pings.
+const defaultCacheTTL = 15 * time.Minute
+
+// asnCache is a simple in-memory TTL cache in front of lookupASN. lookup
+// defaults to lookupASN and is overridable in tests.
+type asnCache struct {
+	ttl       time.Duration
+	lookupASN func(ctx context.Context, asn string) (ASNResult, error)
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	result    ASNResult
+	expiresAt time.Time
+}
+
+func newASNCache(ttl time.Duration) *asnCache {
+	return &asnCache{ttl: ttl, lookupASN: lookupASN, entries: make(map[string]cacheEntry)}
+}
+
+// lookup serves asn from cache when a successful result hasn't expired
+// yet. Failed lookups (timeouts, transient RIPE/DNS errors) are never
+// cached, so a single bad lookup doesn't wedge the API into failing for
+// the full TTL.
+func (c *asnCache) lookup(ctx context.Context, asn string) (ASNResult, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[asn]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.result, nil
+	}
+
+	result, err := c.lookupASN(ctx, asn)
+	if err != nil {
+		return result, err
+	}
+
+	c.mu.Lock()
+	c.entries[asn] = cacheEntry{result: result, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return result, nil
+}
+
+// apiServer serves ASN and IP lookups over HTTP, modeled on echoip's API
+// style: plain JSON by default, plain text for curl/wget clients.
+type apiServer struct {
+	cache *asnCache
+}
+
+func newAPIServer() *apiServer {
+	return &apiServer{cache: newASNCache(defaultCacheTTL)}
+}
+
+func (s *apiServer) router() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/healthz", s.handleHealthz).Methods(http.MethodGet)
+	r.HandleFunc("/asn/{asn}", s.handleASN).Methods(http.MethodGet)
+	r.HandleFunc("/asn/{asn}/prefixes", s.handlePrefixes).Methods(http.MethodGet)
+	r.HandleFunc("/ip/{ip}/location", s.handleIPLocation).Methods(http.MethodGet)
+	return r
+}
+
+func (s *apiServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, "ok")
+}
+
+func (s *apiServer) handleASN(w http.ResponseWriter, r *http.Request) {
+	asn := mux.Vars(r)["asn"]
+	if !asnPattern.MatchString(asn) {
+		http.Error(w, fmt.Sprintf("invalid ASN %q", asn), http.StatusBadRequest)
+		return
+	}
+	result, err := s.cache.lookup(r.Context(), asn)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeResponse(w, r, result)
+}
+
+func (s *apiServer) handlePrefixes(w http.ResponseWriter, r *http.Request) {
+	asn := mux.Vars(r)["asn"]
+	if !asnPattern.MatchString(asn) {
+		http.Error(w, fmt.Sprintf("invalid ASN %q", asn), http.StatusBadRequest)
+		return
+	}
+	result, err := s.cache.lookup(r.Context(), asn)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeResponse(w, r, result.Prefixes)
+}
+
+func (s *apiServer) handleIPLocation(w http.ResponseWriter, r *http.Request) {
+	ip := mux.Vars(r)["ip"]
+	info, err := getIPLocation(r.Context(), ip)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeResponse(w, r, Location{Country: info.Country, Region: info.Region, City: info.City})
+}
+
+// isPlainTextClient reports whether r looks like a bare curl/wget request
+// rather than a browser or API client expecting JSON.
+func isPlainTextClient(r *http.Request) bool {
+	ua := strings.ToLower(r.Header.Get("User-Agent"))
+	return strings.Contains(ua, "curl") || strings.Contains(ua, "wget")
+}
+
+// writeResponse content-negotiates between JSON and plain text for
+// curl/wget clients.
+func writeResponse(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if isPlainTextClient(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%v\n", v)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// serveAPI starts the HTTP API on addr and blocks until it exits.
+func serveAPI(addr string) error {
+	s := newAPIServer()
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.router(),
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	log.Printf("Listening on %s", addr)
+	return server.ListenAndServe()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestASNCacheFailureNotCached(t *testing.T) {
+	c := newASNCache(time.Hour)
+
+	calls := 0
+	c.lookupASN = func(ctx context.Context, asn string) (ASNResult, error) {
+		calls++
+		return ASNResult{}, errors.New("transient RIPE error")
+	}
+
+	if _, err := c.lookup(context.Background(), "15169"); err == nil {
+		t.Fatal("expected an error from the first lookup")
+	}
+	if _, err := c.lookup(context.Background(), "15169"); err == nil {
+		t.Fatal("expected an error from the second lookup")
+	}
+
+	if calls != 2 {
+		t.Errorf("lookupASN called %d times, want 2 (failed lookups must not be cached)", calls)
+	}
+}
+
+func TestASNCacheSuccessIsCached(t *testing.T) {
+	c := newASNCache(time.Hour)
+
+	calls := 0
+	c.lookupASN = func(ctx context.Context, asn string) (ASNResult, error) {
+		calls++
+		return ASNResult{ASN: asn, PingableIP: "8.8.8.8"}, nil
+	}
+
+	first, err := c.lookup(context.Background(), "15169")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := c.lookup(context.Background(), "15169")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("lookupASN called %d times, want 1 (second call should hit cache)", calls)
+	}
+	if first.PingableIP != second.PingableIP {
+		t.Errorf("cached result = %+v, want %+v", second, first)
+	}
+}
+
+func TestASNCacheExpiry(t *testing.T) {
+	c := newASNCache(0) // expires immediately
+
+	calls := 0
+	c.lookupASN = func(ctx context.Context, asn string) (ASNResult, error) {
+		calls++
+		return ASNResult{ASN: asn}, nil
+	}
+
+	c.lookup(context.Background(), "15169")
+	c.lookup(context.Background(), "15169")
+
+	if calls != 2 {
+		t.Errorf("lookupASN called %d times, want 2 (a zero-TTL entry must not be reused)", calls)
+	}
+}
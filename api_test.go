@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestASNPattern covers the shapes handleASN/handlePrefixes accept
+// before ever reaching findPingableNS's ASN parsing.
+func TestASNPattern(t *testing.T) {
+	cases := []struct {
+		asn  string
+		want bool
+	}{
+		{"AS15169", true},
+		{"15169", true},
+		{"", false},
+		{"not-an-asn", false},
+		{"AS", false},
+	}
+	for _, c := range cases {
+		if got := asnPattern.MatchString(c.asn); got != c.want {
+			t.Errorf("asnPattern.MatchString(%q) = %v, want %v", c.asn, got, c.want)
+		}
+	}
+}
+
+// TestHandleASNInvalidInput guards against a regression where an ASN
+// path param without the "AS" prefix (or any other non-numeric value)
+// reached findPingableNS's ASN parsing and panicked the handling
+// goroutine instead of returning a 4xx.
+func TestHandleASNInvalidInput(t *testing.T) {
+	s := newAPIServer()
+	router := s.router()
+
+	for _, asn := range []string{"not-an-asn", "abc123"} {
+		req := httptest.NewRequest("GET", "/asn/"+asn, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != 400 {
+			t.Errorf("asn=%q: status = %d, want 400", asn, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// commonHostPrefixes are prepended to a domain when probing for
+// infrastructure hostnames that might not show up in its NS or MX
+// records.
+var commonHostPrefixes = []string{"www", "mail", "ns1", "ns2", "mx"}
+
+// discoverCandidates gathers candidate IPs for domain from its NS, MX,
+// and SPF-referenced records plus a handful of common infrastructure
+// hostnames, then keeps only the ones that actually belong to asn. This
+// finds far more high-quality candidates than a single NS lookup and,
+// when the ASN owns its own DNS/mail infrastructure, avoids scanning
+// entire prefixes.
+func discoverCandidates(ctx context.Context, domain, asn string) ([]string, error) {
+	names := make(map[string]bool)
+
+	if nsRecords, err := net.DefaultResolver.LookupNS(ctx, domain); err != nil {
+		fmt.Println(newOpError(ctx, "LookupNS", domain, err))
+	} else {
+		for _, ns := range nsRecords {
+			names[strings.TrimSuffix(ns.Host, ".")] = true
+		}
+	}
+
+	if mxRecords, err := net.DefaultResolver.LookupMX(ctx, domain); err != nil {
+		fmt.Println(newOpError(ctx, "LookupMX", domain, err))
+	} else {
+		for _, mx := range mxRecords {
+			names[strings.TrimSuffix(mx.Host, ".")] = true
+		}
+	}
+
+	for _, prefix := range commonHostPrefixes {
+		names[prefix+"."+domain] = true
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+
+	keepIfOwnedByASN := func(ip string) {
+		if seen[ip] {
+			return
+		}
+		seen[ip] = true
+		location, err := getIPLocation(ctx, ip)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if strings.Contains(location.ASN, asn) {
+			candidates = append(candidates, ip)
+		}
+	}
+
+	for name := range names {
+		ips, err := net.DefaultResolver.LookupHost(ctx, name)
+		if err != nil {
+			continue // not every candidate hostname will exist
+		}
+		for _, ip := range ips {
+			keepIfOwnedByASN(ip)
+		}
+	}
+
+	for _, ip := range discoverSPFIPs(ctx, domain, make(map[string]bool)) {
+		keepIfOwnedByASN(ip)
+	}
+
+	return candidates, nil
+}
+
+// discoverSPFIPs walks domain's SPF TXT record, recursing into any
+// include: or redirect= mechanisms, and returns the IPs named by ip4:
+// and ip6: mechanisms. visited guards against recursion loops.
+func discoverSPFIPs(ctx context.Context, domain string, visited map[string]bool) []string {
+	if visited[domain] {
+		return nil
+	}
+	visited[domain] = true
+
+	txtRecords, err := net.DefaultResolver.LookupTXT(ctx, domain)
+	if err != nil {
+		fmt.Println(newOpError(ctx, "LookupTXT", domain, err))
+		return nil
+	}
+
+	var ips []string
+	for _, txt := range txtRecords {
+		recordIPs, includes := parseSPFMechanisms(txt)
+		ips = append(ips, recordIPs...)
+		for _, include := range includes {
+			ips = append(ips, discoverSPFIPs(ctx, include, visited)...)
+		}
+	}
+	return ips
+}
+
+// parseSPFMechanisms parses a single SPF TXT record, returning the
+// literal addresses named by ip4:/ip6: mechanisms and the domains named
+// by include:/redirect= mechanisms (which the caller resolves
+// recursively via DNS). Non-SPF records yield nothing.
+func parseSPFMechanisms(txt string) (ips []string, includes []string) {
+	if !strings.HasPrefix(txt, "v=spf1") {
+		return nil, nil
+	}
+	for _, mechanism := range strings.Fields(txt) {
+		switch {
+		case strings.HasPrefix(mechanism, "ip4:"):
+			ips = append(ips, strings.TrimPrefix(mechanism, "ip4:"))
+		case strings.HasPrefix(mechanism, "ip6:"):
+			ips = append(ips, strings.TrimPrefix(mechanism, "ip6:"))
+		case strings.HasPrefix(mechanism, "include:"):
+			includes = append(includes, strings.TrimPrefix(mechanism, "include:"))
+		case strings.HasPrefix(mechanism, "redirect="):
+			includes = append(includes, strings.TrimPrefix(mechanism, "redirect="))
+		}
+	}
+	return ips, includes
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSPFMechanisms(t *testing.T) {
+	cases := []struct {
+		name         string
+		txt          string
+		wantIPs      []string
+		wantIncludes []string
+	}{
+		{
+			name:    "not an spf record",
+			txt:     "google-site-verification=abc123",
+			wantIPs: nil,
+		},
+		{
+			name:    "ip4 and ip6",
+			txt:     "v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 -all",
+			wantIPs: []string{"192.0.2.0/24", "2001:db8::/32"},
+		},
+		{
+			name:         "include and redirect",
+			txt:          "v=spf1 include:_spf.example.com redirect=_spf2.example.com -all",
+			wantIncludes: []string{"_spf.example.com", "_spf2.example.com"},
+		},
+		{
+			name:         "mixed mechanisms",
+			txt:          "v=spf1 ip4:198.51.100.1 include:_spf.example.net ~all",
+			wantIPs:      []string{"198.51.100.1"},
+			wantIncludes: []string{"_spf.example.net"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ips, includes := parseSPFMechanisms(c.txt)
+			if !reflect.DeepEqual(ips, c.wantIPs) {
+				t.Errorf("ips = %v, want %v", ips, c.wantIPs)
+			}
+			if !reflect.DeepEqual(includes, c.wantIncludes) {
+				t.Errorf("includes = %v, want %v", includes, c.wantIncludes)
+			}
+		})
+	}
+}
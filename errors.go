@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// OpError records a failed network operation. It mirrors the IsTimeout
+// field net.DNSError exposes so callers can special-case timeouts the
+// same way regardless of whether the failure came from DNS, HTTP, or
+// ping.
+type OpError struct {
+	Op        string
+	Target    string
+	Err       error
+	IsTimeout bool
+}
+
+func (e *OpError) Error() string {
+	if e.Target != "" {
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Target, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *OpError) Unwrap() error { return e.Err }
+
+// newOpError wraps err as an OpError for op/target, deriving IsTimeout
+// from ctx's deadline and from err itself when it implements net.Error.
+func newOpError(ctx context.Context, op, target string, err error) *OpError {
+	timeout := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if !timeout {
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			timeout = netErr.Timeout()
+		}
+	}
+	return &OpError{Op: op, Target: target, Err: err, IsTimeout: timeout}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLocator resolves an IP address to geographic and network metadata.
+type GeoLocator interface {
+	Locate(ctx context.Context, ip string) (IPInfo, error)
+}
+
+// HTTPGeoLocator looks up IPs against ipinfo.io, the behavior this tool
+// has always used.
+type HTTPGeoLocator struct{}
+
+func (l *HTTPGeoLocator) Locate(ctx context.Context, ip string) (IPInfo, error) {
+	reqURL := fmt.Sprintf(ipInfoAPIURLTemplate, ip)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return IPInfo{}, newOpError(ctx, "getIPLocation", ip, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return IPInfo{}, newOpError(ctx, "getIPLocation", ip, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return IPInfo{}, newOpError(ctx, "getIPLocation", ip, err)
+	}
+
+	var ipInfo IPInfo
+	if err := json.Unmarshal(body, &ipInfo); err != nil {
+		return IPInfo{}, newOpError(ctx, "getIPLocation", ip, err)
+	}
+
+	return ipInfo, nil
+}
+
+// MMDBGeoLocator looks up IPs against a local MaxMind GeoIP2/GeoLite2
+// City database, avoiding any external API calls.
+type MMDBGeoLocator struct {
+	db *geoip2.Reader
+}
+
+// NewMMDBGeoLocator opens the MMDB file at path for lookups.
+func NewMMDBGeoLocator(path string) (*MMDBGeoLocator, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening geoip db %q: %w", path, err)
+	}
+	return &MMDBGeoLocator{db: db}, nil
+}
+
+func (l *MMDBGeoLocator) Close() error {
+	return l.db.Close()
+}
+
+func (l *MMDBGeoLocator) Locate(ctx context.Context, ip string) (IPInfo, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return IPInfo{}, fmt.Errorf("invalid IP %q", ip)
+	}
+
+	record, err := l.db.City(parsed)
+	if err != nil {
+		return IPInfo{}, fmt.Errorf("looking up %s in geoip db: %w", ip, err)
+	}
+
+	info := IPInfo{
+		City:    record.City.Names["en"],
+		Country: record.Country.IsoCode,
+	}
+	if len(record.Subdivisions) > 0 {
+		info.Region = record.Subdivisions[0].Names["en"]
+	}
+	return info, nil
+}
+
+// FallbackGeoLocator tries primary first and falls back to secondary on
+// error, or when primary left ASN unset. This lets a local City MMDB
+// (which has no ASN data at all) be used for country/region/city while
+// still producing an ASN via the ipinfo.io API when needed.
+type FallbackGeoLocator struct {
+	primary   GeoLocator
+	secondary GeoLocator
+}
+
+func NewFallbackGeoLocator(primary, secondary GeoLocator) *FallbackGeoLocator {
+	return &FallbackGeoLocator{primary: primary, secondary: secondary}
+}
+
+func (l *FallbackGeoLocator) Locate(ctx context.Context, ip string) (IPInfo, error) {
+	info, err := l.primary.Locate(ctx, ip)
+	if err != nil {
+		return l.secondary.Locate(ctx, ip)
+	}
+	if info.ASN == "" {
+		if fallback, ferr := l.secondary.Locate(ctx, ip); ferr == nil {
+			info.ASN = fallback.ASN
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type stubGeoLocator struct {
+	info IPInfo
+	err  error
+}
+
+func (s stubGeoLocator) Locate(ctx context.Context, ip string) (IPInfo, error) {
+	return s.info, s.err
+}
+
+func TestFallbackGeoLocatorBackfillsASN(t *testing.T) {
+	primary := stubGeoLocator{info: IPInfo{City: "Mountain View", Country: "US"}}
+	secondary := stubGeoLocator{info: IPInfo{ASN: "AS15169"}}
+
+	l := NewFallbackGeoLocator(primary, secondary)
+	got, err := l.Locate(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Locate returned error: %v", err)
+	}
+
+	want := IPInfo{City: "Mountain View", Country: "US", ASN: "AS15169"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Locate() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFallbackGeoLocatorPrimaryError(t *testing.T) {
+	primary := stubGeoLocator{err: errors.New("mmdb miss")}
+	secondary := stubGeoLocator{info: IPInfo{ASN: "AS15169", Country: "US"}}
+
+	l := NewFallbackGeoLocator(primary, secondary)
+	got, err := l.Locate(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Locate returned error: %v", err)
+	}
+	if got != secondary.info {
+		t.Errorf("Locate() = %+v, want secondary's result %+v", got, secondary.info)
+	}
+}
+
+func TestFallbackGeoLocatorPrimaryHasASN(t *testing.T) {
+	primary := stubGeoLocator{info: IPInfo{ASN: "AS15169", Country: "US"}}
+	secondary := stubGeoLocator{info: IPInfo{ASN: "AS64512", Country: "DE"}}
+
+	l := NewFallbackGeoLocator(primary, secondary)
+	got, err := l.Locate(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Locate returned error: %v", err)
+	}
+	if got != primary.info {
+		t.Errorf("Locate() = %+v, want primary's result %+v unchanged", got, primary.info)
+	}
+}
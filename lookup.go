@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Location is the subset of IPInfo that's safe to expose over the API;
+// ASN is omitted since it isn't always available (e.g. from a City MMDB).
+type Location struct {
+	Country string `json:"country"`
+	Region  string `json:"region"`
+	City    string `json:"city"`
+}
+
+// ASNResult is the outcome of resolving reachability information for an
+// ASN: its announced prefixes, the first pingable IP found, and that
+// IP's location.
+type ASNResult struct {
+	ASN         string   `json:"asn"`
+	Prefixes    []string `json:"prefixes"`
+	PingableIP  string   `json:"pingable_ip,omitempty"`
+	ProbeMethod string   `json:"probe_method,omitempty"`
+	Location    Location `json:"location"`
+}
+
+// lookupASN fetches asn's announced prefixes, finds a pingable IP within
+// them (preferring the ASN's own nameserver), and resolves that IP's
+// location. It's the shared core used by both the CLI and the HTTP API.
+// ctx bounds the whole pipeline; a timeout or cancellation surfaces as an
+// error so the caller can move on to the next ASN.
+func lookupASN(ctx context.Context, asn string) (ASNResult, error) {
+	startTime := time.Now().Add(-24 * time.Hour).Unix()
+	prefixes, err := fetchPrefixes(ctx, asn, startTime)
+	if err != nil {
+		return ASNResult{ASN: asn}, err
+	}
+
+	result := ASNResult{ASN: asn, Prefixes: prefixes}
+
+	if ns, method, err := findPingableNS(ctx, asn); err != nil {
+		fmt.Println(err)
+	} else if ns != "" {
+		lookupIPs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", ns)
+		if err != nil {
+			fmt.Println(newOpError(ctx, "LookupNetIP", ns, err))
+		} else if len(lookupIPs) > 0 {
+			location, err := getIPLocation(ctx, lookupIPs[0].String())
+			if err != nil {
+				return result, err
+			}
+			if strings.Contains(location.ASN, asn) {
+				result.PingableIP = ns
+				result.ProbeMethod = method
+				result.Location = Location{Country: location.Country, Region: location.Region, City: location.City}
+				return result, nil
+			}
+		}
+	}
+
+	ip, method, err := findPingableIP(ctx, prefixes, asn)
+	if err != nil {
+		return result, err
+	}
+	if ip != "" {
+		location, err := getIPLocation(ctx, ip)
+		if err != nil {
+			return result, err
+		}
+		result.PingableIP = ip
+		result.ProbeMethod = method
+		result.Location = Location{Country: location.Country, Region: location.Region, City: location.City}
+		return result, nil
+	}
+
+	return result, fmt.Errorf("no pingable IP found for %s", asn)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// TestLookupASNBareNumericASN guards against findPingableNS's fixed
+// "AS" prefix panic resurfacing through the shared lookupASN entry
+// point. The context is pre-canceled so the test fails fast instead of
+// reaching RIPE; what matters is that a bare numeric ASN like "15169"
+// never panics on its way through.
+func TestLookupASNBareNumericASN(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("lookupASN panicked on a bare numeric ASN: %v", r)
+		}
+	}()
+
+	if _, err := lookupASN(ctx, "15169"); err == nil {
+		t.Fatal("expected an error from a pre-canceled context, got nil")
+	}
+}
@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
-	"net"
-	"net/url"
+	mrand "math/rand"
 	"net/http"
-	"strconv"
+	"net/netip"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -43,18 +44,63 @@ type IPInfo struct {
 }
 
 type CountryIPASN struct {
-	IP  string
-	ASN string
+	IP     string
+	ASN    string
+	Method string // probe method that found the IP reachable: "icmp" or "tcp"
 }
 
+// geoLocator is the GeoLocator used by getIPLocation. It defaults to the
+// ipinfo.io HTTP backend and is swapped for an MMDB-backed one when
+// -geoip-db is provided.
+var geoLocator GeoLocator = &HTTPGeoLocator{}
+
 func main() {
 	asn := flag.String("asn", "", "ASN to retrieve prefixes and ping IPs")
+	geoipDB := flag.String("geoip-db", "", "path to a local MaxMind GeoIP2/GeoLite2 City MMDB; falls back to ipinfo.io on miss")
+	listenAddr := flag.String("listen", "", "run an HTTP API server on this address (e.g. :8080) instead of a one-shot scan")
+	timeout := flag.Duration("timeout", 30*time.Second, "per-ASN deadline for the whole fetch/ping/locate pipeline")
+	probe := flag.String("probe", string(ProbeICMP), "reachability probe to use: icmp, tcp, or both")
+	tcpPorts := flag.String("tcp-ports", "80,443,22,53", "comma-separated TCP ports to try when -probe is tcp or both")
+	v6Samples := flag.Int("v6-samples", v6SampleSize, "number of pseudo-random host addresses to sample per IPv6 prefix")
 	flag.Parse()
 
+	v6SampleSize = *v6Samples
+
+	if *geoipDB != "" {
+		mmdb, err := NewMMDBGeoLocator(*geoipDB)
+		if err != nil {
+			log.Fatalf("Error loading geoip db: %v", err)
+		}
+		defer mmdb.Close()
+		geoLocator = NewFallbackGeoLocator(mmdb, &HTTPGeoLocator{})
+	}
+
+	switch ProbeMode(*probe) {
+	case ProbeICMP, ProbeTCP, ProbeBoth:
+		probeMode = ProbeMode(*probe)
+	default:
+		log.Fatalf("Invalid -probe value %q: must be icmp, tcp, or both", *probe)
+	}
+
+	ports, err := parseTCPPorts(*tcpPorts)
+	if err != nil {
+		log.Fatalf("Invalid -tcp-ports value: %v", err)
+	}
+	tcpProbePorts = ports
+
+	ctx := context.Background()
+
+	if *listenAddr != "" {
+		if err := serveAPI(*listenAddr); err != nil {
+			log.Fatalf("Error running API server: %v", err)
+		}
+		return
+	}
+
 	if *asn == "" {
 		log.Fatal("ASN is required. Use -asn flag to provide an ASN.")
 	}
-	startTime := time.Now().Add(-24 * time.Hour).Unix()
+
 	countryMap := make(map[string][]CountryIPASN)
 
 	var asnList []string
@@ -63,65 +109,41 @@ func main() {
 	} else {
 		asnList = append(asnList, *asn)
 	}
-	for i := 0; i < len(asnList); i++ {
-		asNumber := asnList[i]
-		prefixes := fetchPrefixes(asNumber, startTime)
-
-		pingNS := findPingableNS(asNumber)
-		var ipFound bool
-		if pingNS != "" {
-			lookupIP, err := net.LookupIP(pingNS)
-			if err != nil {
-				fmt.Println("Error:", err)
-				return
-			}
-			var targetIP string
-			for _, ip := range lookupIP {
-				targetIP = ip.String()
-				break
-			}
-			location := getIPLocation(targetIP)
-			if strings.Contains(location.ASN, asNumber) {
-				ipFound = true
-				countryMap[location.Country] = append(countryMap[location.Country], CountryIPASN{IP: pingNS, ASN: asNumber})
-
-			}
-		}
-		if ipFound {
+	for _, asNumber := range asnList {
+		asnCtx, cancel := context.WithTimeout(ctx, *timeout)
+		result, err := lookupASN(asnCtx, asNumber)
+		cancel()
+		if err != nil {
+			fmt.Println(err)
 			continue
 		}
-		// Step 2: Ping the IP addresses within the prefixes
-		pingTarget := findPingableIP(prefixes, asNumber)
-
-		// Step 3: Retrieve location information of the first pingable IP
-		if pingTarget != "" {
-			var location IPInfo
-			location = getIPLocation(pingTarget)
-			countryMap[location.Country] = append(countryMap[location.Country], CountryIPASN{IP: pingTarget, ASN: asNumber})
-		} else {
-			fmt.Println("No pingable IP found.")
-		}
+		countryMap[result.Location.Country] = append(countryMap[result.Location.Country], CountryIPASN{IP: result.PingableIP, ASN: asNumber, Method: result.ProbeMethod})
 	}
 	fmt.Println(countryMap)
 }
 
 // fetchPrefixes fetches the announced prefixes for a given ASN from RIPE
-func fetchPrefixes(asn string, startTime int64) []string {
-	url := fmt.Sprintf(ripeAPIURLTemplate, asn, startTime)
-	resp, err := http.Get(url)
+func fetchPrefixes(ctx context.Context, asn string, startTime int64) ([]string, error) {
+	reqURL := fmt.Sprintf(ripeAPIURLTemplate, asn, startTime)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, newOpError(ctx, "fetchPrefixes", asn, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatalf("Error fetching prefixes: %v", err)
+		return nil, newOpError(ctx, "fetchPrefixes", asn, err)
 	}
 	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
+		return nil, newOpError(ctx, "fetchPrefixes", asn, err)
 	}
 
 	var data PrefixData
 	if err := json.Unmarshal(body, &data); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
+		return nil, newOpError(ctx, "fetchPrefixes", asn, err)
 	}
 
 	var prefixes []string
@@ -129,14 +151,21 @@ func fetchPrefixes(asn string, startTime int64) []string {
 		prefixes = append(prefixes, prefix.Prefix)
 	}
 
-	return prefixes
+	return prefixes, nil
 }
-func findPingableNS(asn string) string {
-	asnURL := getDomainFromPeeringDB(strings.Split(asn, "AS")[1])
+
+// findPingableNS resolves the ASN's registered domain (via PeeringDB) and
+// pings the DNS/mail/web infrastructure hostnames discovered for it,
+// preferring these over a full prefix sweep when the ASN owns its own
+// infrastructure.
+func findPingableNS(ctx context.Context, asn string) (string, string, error) {
+	asnURL, err := getDomainFromPeeringDB(ctx, strings.TrimPrefix(asn, "AS"))
+	if err != nil {
+		return "", "", err
+	}
 	parsedURL, err := url.Parse(asnURL)
 	if err != nil {
-		fmt.Println("Error parsing URL:", err)
-		return ""
+		return "", "", newOpError(ctx, "parseURL", asnURL, err)
 	}
 
 	// Get the host (which includes the domain and port if present)
@@ -148,54 +177,174 @@ func findPingableNS(asn string) string {
 
 		}
 	}
-	nsServer := getNSServer(domain)
-	results := parallelPing([]string{nsServer})
-	fmt.Println(results)
-	for _, reachable := range results {
-		if reachable {
-			return nsServer
+	if domain == "" {
+		return "", "", nil
+	}
+
+	candidates, err := discoverCandidates(ctx, domain, asn)
+	if err != nil {
+		return "", "", err
+	}
+	if len(candidates) == 0 {
+		return "", "", nil
+	}
+	return pingBatch(ctx, candidates)
+}
+
+// findPingableIP finds the first pingable IP within the list of prefixes.
+// IPv4 prefixes are swept sequentially; IPv6 prefixes are far too large to
+// sweep, so a bounded set of candidate addresses is sampled instead.
+func findPingableIP(ctx context.Context, prefixes []string, asn string) (string, string, error) {
+	const concurrent = 10
+
+	for _, p := range prefixes {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			fmt.Printf("Skipping invalid prefix %q: %v\n", p, err)
+			continue
+		}
+
+		var ip, method string
+		if prefix.Addr().Is6() {
+			ip, method, err = scanV6Prefix(ctx, prefix, v6SampleSize)
 		} else {
-			fmt.Printf("%s is not reachable\n", nsServer)
+			ip, method, err = scanV4Prefix(ctx, prefix, concurrent)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if ip != "" {
+			return ip, method, nil
+		}
+	}
+	return "", "", nil
+}
+
+// scanV4Prefix sequentially sweeps every host address in prefix, pinging
+// `concurrent` addresses at a time. The network and broadcast addresses
+// are excluded, except in /31 and /32 prefixes, which have no distinct
+// network/broadcast address: a /32 is a single host route and a /31 is
+// a point-to-point link where both addresses are valid hosts (RFC 3021).
+func scanV4Prefix(ctx context.Context, prefix netip.Prefix, concurrent int) (string, string, error) {
+	first := prefix.Addr()
+	stopAt := broadcastAddr(prefix)
+	if prefix.Bits() < 31 {
+		// Skip the network address and stop before the broadcast address.
+		first = first.Next()
+	} else {
+		// /31 and /32: no distinct network/broadcast address, so include
+		// every address up to and including stopAt.
+		stopAt = stopAt.Next()
+	}
+
+	var pingList []string
+	for addr := first; prefix.Contains(addr) && addr != stopAt; addr = addr.Next() {
+		pingList = append(pingList, addr.String())
+		if len(pingList) == concurrent {
+			ip, method, err := pingBatch(ctx, pingList)
+			if err != nil || ip != "" {
+				return ip, method, err
+			}
+			pingList = nil
+		}
+	}
+	return pingBatch(ctx, pingList)
+}
+
+// broadcastAddr returns the last address of an IPv4 prefix (all host
+// bits set), e.g. 192.0.2.255 for 192.0.2.0/24.
+func broadcastAddr(prefix netip.Prefix) netip.Addr {
+	network := prefix.Addr().As4()
+	bits := prefix.Bits()
+	for i := range network {
+		bitStart := i * 8
+		switch {
+		case bitStart+8 <= bits:
+			// fully within the network portion, keep it as-is
+		case bitStart >= bits:
+			network[i] = 0xFF
+		default:
+			maskBits := bits - bitStart
+			mask := byte(0xFF << (8 - maskBits))
+			network[i] |= ^mask
 		}
 	}
-	return ""
+	return netip.AddrFrom4(network)
 }
 
-// findPingableIP finds the first pingable IP within the list of prefixes
-func findPingableIP(prefixes []string, asn string) string {
-	concurrent := 10
-	for _, prefix := range prefixes {
-		if strings.Contains(prefix, ":") {
+// v6SampleSize is the number of pseudo-random host candidates generated
+// per IPv6 prefix, in addition to the prefix address and its successor.
+// Configurable via -v6-samples.
+var v6SampleSize = 64
+
+// scanV6Prefix pings a bounded set of candidate addresses within an IPv6
+// prefix rather than sweeping the full (potentially 2^64-host) space.
+func scanV6Prefix(ctx context.Context, prefix netip.Prefix, sampleSize int) (string, string, error) {
+	candidates := []netip.Addr{prefix.Addr(), prefix.Addr().Next()}
+	for i := 0; i < sampleSize; i++ {
+		candidates = append(candidates, randomAddrInPrefix(prefix))
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	var pingList []string
+	for _, addr := range candidates {
+		s := addr.String()
+		if seen[s] {
 			continue
 		}
-		ipRange := strings.Split(prefix, "0/")[0]
-		for i := 1; i < 255; i++ {
-			var pingList []string
-			if i != 1 {
-				for j := 0; j < concurrent; j++ {
-					pingList = append(pingList, ipRange+strconv.Itoa(i))
-					i++
-				}
-			} else {
-				pingList = append(pingList, ipRange+strconv.Itoa(i))
-			}
-			results := parallelPing(pingList)
-
-			for ip, reachable := range results {
-				if reachable {
-					return ip
-				} else {
-					fmt.Printf("%s is not reachable\n", ip)
-				}
-			}
+		seen[s] = true
+		pingList = append(pingList, s)
+	}
+	return pingBatch(ctx, pingList)
+}
+
+// randomAddrInPrefix produces a pseudo-random address inside prefix by
+// masking a random 128-bit value with the prefix's network bits.
+func randomAddrInPrefix(prefix netip.Prefix) netip.Addr {
+	network := prefix.Addr().As16()
+	var random [16]byte
+	mrand.Read(random[:])
+
+	bits := prefix.Bits()
+	for i := range network {
+		bitStart := i * 8
+		switch {
+		case bitStart+8 <= bits:
+			random[i] = network[i]
+		case bitStart >= bits:
+			// fully within the host portion, keep the random byte
+		default:
+			maskBits := bits - bitStart
+			mask := byte(0xFF << (8 - maskBits))
+			random[i] = (network[i] & mask) | (random[i] &^ mask)
+		}
+	}
+	return netip.AddrFrom16(random)
+}
+
+// pingBatch pings ips concurrently and returns the first reachable one
+// along with the probe method that found it, or "" if none responded.
+func pingBatch(ctx context.Context, ips []string) (string, string, error) {
+	results, err := parallelPing(ctx, ips)
+	if err != nil {
+		return "", "", err
+	}
+	for ip, outcome := range results {
+		if outcome.Reachable {
+			return ip, outcome.Method, nil
 		}
+		fmt.Printf("%s is not reachable\n", ip)
 	}
-	return ""
+	return "", "", nil
 }
 
 // parallelPing pings a list of IPs concurrently and returns a map of results
-func parallelPing(ips []string) map[string]bool {
-	results := make(map[string]bool)
+func parallelPing(ctx context.Context, ips []string) (map[string]probeOutcome, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newOpError(ctx, "parallelPing", "", err)
+	}
+
+	results := make(map[string]probeOutcome)
 	var wg sync.WaitGroup
 	mu := &sync.Mutex{}
 
@@ -203,19 +352,19 @@ func parallelPing(ips []string) map[string]bool {
 		wg.Add(1)
 		go func(ip string) {
 			defer wg.Done()
-			reachable := isReachable(ip)
+			reachable, method := isReachable(ctx, ip)
 			mu.Lock()
-			results[ip] = reachable
+			results[ip] = probeOutcome{Reachable: reachable, Method: method}
 			mu.Unlock()
 		}(ip)
 	}
 
 	wg.Wait()
-	return results
+	return results, nil
 }
 
-// isReachable uses go-ping to check if an IP is reachable
-func isReachable(ip string) bool {
+// isReachableICMP uses go-ping to check if an IP responds to ICMP echo
+func isReachableICMP(ctx context.Context, ip string) bool {
 	pinger, err := ping.NewPinger(ip)
 	if err != nil {
 		fmt.Printf("ERROR: %s\n", err.Error())
@@ -225,77 +374,64 @@ func isReachable(ip string) bool {
 	pinger.Timeout = time.Second
 	pinger.SetPrivileged(true) // Required for privileged ICMP requests
 
-	err = pinger.Run() // Blocks until finished
-	if err != nil {
-		fmt.Printf("ERROR: %s\n", err.Error())
+	if addr, err := netip.ParseAddr(ip); err == nil && addr.Is6() {
+		pinger.SetNetwork("ip6")
+	}
+
+	// go-ping's Pinger has no RunWithContext, so run it in a goroutine and
+	// stop it ourselves if ctx is done first.
+	done := make(chan error, 1)
+	go func() { done <- pinger.Run() }()
+
+	select {
+	case <-ctx.Done():
+		pinger.Stop()
+		<-done
 		return false
+	case err = <-done:
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err.Error())
+			return false
+		}
 	}
 
 	stats := pinger.Statistics() // Get send/receive/rtt stats
 	return stats.PacketsRecv > 0
 }
 
-// getIPLocation retrieves the geographical location of an IP using ipinfo.io
-func getIPLocation(ip string) IPInfo {
-	url := fmt.Sprintf(ipInfoAPIURLTemplate, ip)
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatalf("Error fetching IP location: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Error reading response: %v", err)
-	}
-
-	var ipInfo IPInfo
-	if err := json.Unmarshal(body, &ipInfo); err != nil {
-		log.Fatalf("Error parsing JSON: %v", err)
-	}
-
-	return ipInfo
+// getIPLocation retrieves the geographical location of an IP using the
+// configured geoLocator (ipinfo.io by default, or a local MMDB when
+// -geoip-db is set).
+func getIPLocation(ctx context.Context, ip string) (IPInfo, error) {
+	return geoLocator.Locate(ctx, ip)
 }
 
-func getNSServer(domain string) string {
-	nsRecords, err := net.LookupNS(domain)
+func getDomainFromPeeringDB(ctx context.Context, asn string) (string, error) {
+	reqURL := fmt.Sprintf("https://www.peeringdb.com/api/net?asn=%s", asn)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
-		fmt.Printf("Failed to perform NS lookup: %v\n", err)
-		return ""
-	}
-	for _, ns := range nsRecords {
-		fmt.Printf("  %s\n", ns.Host)
-		return ns.Host
+		return "", newOpError(ctx, "getDomainFromPeeringDB", asn, err)
 	}
-	return ""
-}
 
-func getDomainFromPeeringDB(asn string) string {
-	url := fmt.Sprintf("https://www.peeringdb.com/api/net?asn=%s", asn)
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Fatalf("Failed to query PeeringDB API: %v", err)
+		return "", newOpError(ctx, "getDomainFromPeeringDB", asn, err)
 	}
 	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatalf("Failed to read response body: %v", err)
+		return "", newOpError(ctx, "getDomainFromPeeringDB", asn, err)
 	}
 
-	// Parse the JSON response
 	var pdbResponse PeeringDBResponse
 	if err := json.Unmarshal(body, &pdbResponse); err != nil {
-		log.Fatalf("Failed to parse JSON response: %v", err)
+		return "", newOpError(ctx, "getDomainFromPeeringDB", asn, err)
 	}
 
-	// Extract and print the website
 	if len(pdbResponse.Data) > 0 && pdbResponse.Data[0].Website != "" {
-		fmt.Printf("Website for ASN %s: %s\n", asn, pdbResponse.Data[0].Website)
-		return pdbResponse.Data[0].Website
-	} else {
-		fmt.Printf("No website found for ASN %s\n", asn)
+		return pdbResponse.Data[0].Website, nil
 	}
-	return ""
+	fmt.Printf("No website found for ASN %s\n", asn)
+	return "", nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+)
+
+// TestFindPingableNSBareNumericASN guards against a regression where
+// findPingableNS panicked (index out of range) on an ASN string that
+// lacks the "AS" prefix, e.g. "15169" instead of "AS15169" — a
+// perfectly normal way to pass -asn. The context is pre-canceled so the
+// test fails fast on the network call instead of actually reaching
+// PeeringDB; what it's checking is that the ASN gets trimmed safely
+// before that call, not what the call returns.
+func TestFindPingableNSBareNumericASN(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("findPingableNS panicked on a bare numeric ASN: %v", r)
+		}
+	}()
+
+	if _, _, err := findPingableNS(ctx, "15169"); err == nil {
+		t.Fatal("expected an error from a pre-canceled context, got nil")
+	}
+}
+
+func TestRandomAddrInPrefix(t *testing.T) {
+	prefixes := []string{
+		"2001:db8::/32",
+		"2001:db8:1234::/48",
+		"fe80::/64",
+		"::/0",
+	}
+	for _, p := range prefixes {
+		prefix := netip.MustParsePrefix(p)
+		for i := 0; i < 1000; i++ {
+			addr := randomAddrInPrefix(prefix)
+			if !prefix.Contains(addr) {
+				t.Fatalf("randomAddrInPrefix(%s) produced %s, not contained in the prefix", p, addr)
+			}
+		}
+	}
+}
+
+func TestBroadcastAddr(t *testing.T) {
+	cases := []struct {
+		prefix string
+		want   string
+	}{
+		{"192.0.2.0/24", "192.0.2.255"},
+		{"192.0.2.0/30", "192.0.2.3"},
+		{"10.0.0.0/8", "10.255.255.255"},
+		{"198.51.100.4/32", "198.51.100.4"},
+	}
+	for _, c := range cases {
+		prefix := netip.MustParsePrefix(c.prefix)
+		got := broadcastAddr(prefix)
+		want := netip.MustParseAddr(c.want)
+		if got != want {
+			t.Errorf("broadcastAddr(%s) = %s, want %s", c.prefix, got, want)
+		}
+	}
+}
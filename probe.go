@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ProbeMode selects which reachability probe(s) isReachable uses.
+type ProbeMode string
+
+const (
+	ProbeICMP ProbeMode = "icmp"
+	ProbeTCP  ProbeMode = "tcp"
+	ProbeBoth ProbeMode = "both"
+)
+
+// probeMode and tcpProbePorts are configured from the -probe and
+// -tcp-ports flags in main.
+var (
+	probeMode     = ProbeICMP
+	tcpProbePorts = []int{80, 443, 22, 53}
+)
+
+// probeOutcome records whether a ping target was reachable and, if so,
+// which probe method found it.
+type probeOutcome struct {
+	Reachable bool
+	Method    string
+}
+
+// isReachable probes ip using the configured probeMode and reports
+// whether it's reachable along with the method that succeeded.
+func isReachable(ctx context.Context, ip string) (bool, string) {
+	switch probeMode {
+	case ProbeTCP:
+		return isReachableTCP(ctx, ip, tcpProbePorts), "tcp"
+	case ProbeBoth:
+		return raceProbes(ctx, ip)
+	default:
+		return isReachableICMP(ctx, ip), "icmp"
+	}
+}
+
+// raceProbes runs the ICMP and TCP probes concurrently and returns as
+// soon as either reports the host reachable.
+func raceProbes(ctx context.Context, ip string) (bool, string) {
+	ch := make(chan probeOutcome, 2)
+	go func() { ch <- probeOutcome{isReachableICMP(ctx, ip), "icmp"} }()
+	go func() { ch <- probeOutcome{isReachableTCP(ctx, ip, tcpProbePorts), "tcp"} }()
+
+	first := <-ch
+	if first.Reachable {
+		return true, first.Method
+	}
+	second := <-ch
+	return second.Reachable, second.Method
+}
+
+// parseTCPPorts parses a comma-separated list of TCP ports, as accepted
+// by the -tcp-ports flag.
+func parseTCPPorts(s string) ([]int, error) {
+	var ports []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		port, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// isReachableTCP attempts a short-timeout TCP handshake against each of
+// ports in turn, treating a successful connect or an ECONNREFUSED reply
+// (which still proves the host is alive) as reachable. This catches hosts
+// that silently drop ICMP.
+func isReachableTCP(ctx context.Context, ip string, ports []int) bool {
+	dialer := net.Dialer{Timeout: time.Second}
+	for _, port := range ports {
+		addr := net.JoinHostPort(ip, strconv.Itoa(port))
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+	}
+	return false
+}